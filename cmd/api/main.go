@@ -0,0 +1,145 @@
+/*
+Copyright 2021 The TestGrid Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command api serves the TestGrid v1 API over HTTP/JSON and gRPC.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+
+	v1 "github.com/GoogleCloudPlatform/testgrid/pkg/api/v1"
+	"github.com/GoogleCloudPlatform/testgrid/util/gcs"
+)
+
+type options struct {
+	httpPort     int
+	grpcPort     int
+	timeout      time.Duration
+	otlpEndpoint string
+	cacheSize    int
+	cacheTTL     time.Duration
+
+	creds          string
+	configPath     string
+	gridPathPrefix string
+	tabPathPrefix  string
+}
+
+func gatherOptions() options {
+	var o options
+	flag.IntVar(&o.httpPort, "port", 8080, "TCP port to bind the HTTP/JSON API to")
+	flag.IntVar(&o.grpcPort, "grpc-port", 0, "TCP port to bind the gRPC API to; 0 disables the gRPC listener")
+	flag.DurationVar(&o.timeout, "timeout", 30*time.Second, "Deadline for serving a single request")
+	flag.StringVar(&o.otlpEndpoint, "otlp-endpoint", "", "OTLP gRPC collector endpoint to export traces to; empty disables tracing export")
+	flag.IntVar(&o.cacheSize, "grid-cache-size", 100, "Number of parsed grids to keep in the in-process cache; 0 disables caching")
+	flag.DurationVar(&o.cacheTTL, "grid-cache-ttl", 30*time.Second, "How long a cached grid is served before being re-fetched from GCS; 0 disables caching")
+	flag.StringVar(&o.creds, "gcs-credentials", "", "Path to a GCS service account credentials file; empty uses application default credentials")
+	flag.StringVar(&o.configPath, "config", "", "GCS path to the default scope's TestGrid Config proto, e.g. gs://k8s-testgrid/config")
+	flag.StringVar(&o.gridPathPrefix, "grid-path-prefix", "grid", "Object prefix under the config's bucket that grid protos are read from")
+	flag.StringVar(&o.tabPathPrefix, "tab-path-prefix", "tabs", "Object prefix under the config's bucket that tab-state protos are read from")
+	flag.Parse()
+	if o.configPath == "" {
+		logrus.Fatal("--config is required")
+	}
+	return o
+}
+
+// initTracing wires the global OpenTelemetry tracer provider to an OTLP
+// collector when an endpoint is configured, so api.ListRows/ListHeaders spans
+// leave the process instead of being dropped on the floor.
+func initTracing(ctx context.Context, endpoint string) (func(context.Context) error, error) {
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithEndpoint(endpoint), otlptracegrpc.WithInsecure())
+	if err != nil {
+		return nil, fmt.Errorf("otlp exporter: %w", err)
+	}
+	tp := trace.NewTracerProvider(trace.WithBatcher(exporter))
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+func main() {
+	opt := gatherOptions()
+	ctx := context.Background()
+
+	shutdownTracing, err := initTracing(ctx, opt.otlpEndpoint)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to initialize tracing")
+	}
+	defer shutdownTracing(context.Background())
+
+	client, err := gcs.ClientWithCreds(ctx, opt.creds)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to create GCS client")
+	}
+
+	server := &v1.Server{
+		Client:         client,
+		DefaultConfig:  opt.configPath,
+		GridPathPrefix: opt.gridPathPrefix,
+		TabPathPrefix:  opt.tabPathPrefix,
+		Timeout:        opt.timeout,
+		Cache:          v1.NewGridCache(opt.cacheSize, opt.cacheTTL),
+	}
+
+	router := mux.NewRouter()
+	v1.Router(router, server)
+	router.Handle("/metrics", promhttp.Handler())
+
+	if opt.grpcPort != 0 {
+		go serveGRPC(opt.grpcPort, server)
+	}
+
+	addr := fmt.Sprintf(":%d", opt.httpPort)
+	logrus.WithField("addr", addr).Info("Serving HTTP API")
+	if err := http.ListenAndServe(addr, router); err != nil {
+		logrus.WithError(err).Fatal("HTTP API server failed")
+	}
+}
+
+// serveGRPC binds and runs the gRPC listener alongside the HTTP mux, letting
+// clients use standard gRPC deadlines/cancellation for long-running dashboard
+// queries instead of the all-in-memory JSON marshal of the HTTP handlers.
+func serveGRPC(port int, server *v1.Server) {
+	addr := fmt.Sprintf(":%d", port)
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to bind gRPC listener")
+	}
+
+	grpcServer := grpc.NewServer()
+	v1.RegisterGRPC(grpcServer, server)
+
+	logrus.WithField("addr", addr).Info("Serving gRPC API")
+	if err := grpcServer.Serve(lis); err != nil {
+		logrus.WithError(err).Fatal("gRPC API server failed")
+	}
+}
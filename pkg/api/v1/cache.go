@@ -0,0 +1,163 @@
+/*
+Copyright 2021 The TestGrid Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	statepb "github.com/GoogleCloudPlatform/testgrid/pb/state"
+)
+
+// GridCache is an in-process, bounded LRU cache of recently downloaded
+// grids, keyed by the resolved GCS path they were read from. It exists to
+// cut GCS egress and p99 latency on hot dashboards, where ListHeaders and
+// ListRows would otherwise each re-download and re-parse the same tab-state
+// proto on every call.
+type GridCache struct {
+	size int
+	ttl  time.Duration
+
+	group singleflight.Group
+
+	mu    sync.Mutex
+	lru   *list.List               // of *cacheEntry, front = most recently used
+	index map[string]*list.Element // keyed by GCS path
+}
+
+// cacheEntry is the value stored in the cache: a parsed grid plus the GCS
+// generation it was read at, so callers can compute an ETag and detect
+// staleness without re-downloading.
+type cacheEntry struct {
+	path       string
+	grid       *statepb.Grid
+	generation int64
+	expires    time.Time
+}
+
+// NewGridCache constructs a GridCache holding at most size entries, each
+// valid for ttl after being fetched. A size or ttl <= 0 disables caching:
+// every Get results in a fetch, and nothing is ever stored.
+func NewGridCache(size int, ttl time.Duration) *GridCache {
+	return &GridCache{
+		size:  size,
+		ttl:   ttl,
+		lru:   list.New(),
+		index: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the cached grid and generation for path if present and
+// unexpired. Concurrent misses for the same path are collapsed into a single
+// call to fetch via singleflight, so a thundering herd of requests for a
+// popular tab issues only one GCS download.
+func (c *GridCache) Get(ctx context.Context, path string, fetch func(context.Context) (*statepb.Grid, int64, error)) (*statepb.Grid, int64, error) {
+	if c == nil || c.size <= 0 || c.ttl <= 0 {
+		return fetch(ctx)
+	}
+
+	if entry, ok := c.lookup(path); ok {
+		return entry.grid, entry.generation, nil
+	}
+
+	v, err, _ := c.group.Do(path, func() (interface{}, error) {
+		grid, generation, err := fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.store(path, grid, generation)
+		return &cacheEntry{path: path, grid: grid, generation: generation}, nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	entry := v.(*cacheEntry)
+	return entry.grid, entry.generation, nil
+}
+
+func (c *GridCache) lookup(path string) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.index[path]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if c.ttl > 0 && time.Now().After(entry.expires) {
+		c.lru.Remove(elem)
+		delete(c.index, path)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return entry, true
+}
+
+// gridETag formats a GCS object generation as an HTTP ETag.
+func gridETag(generation int64) string {
+	return fmt.Sprintf("%q", fmt.Sprintf("gen-%d", generation))
+}
+
+// checkNotModified compares the request's If-None-Match header against
+// generation and, on a match, writes a 304 response and returns true. The
+// caller should return immediately without writing a body when this returns
+// true.
+func checkNotModified(w http.ResponseWriter, r *http.Request, generation int64) bool {
+	etag := gridETag(generation)
+	w.Header().Set("ETag", etag)
+	if match := r.Header.Get("If-None-Match"); match != "" && match == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+func (c *GridCache) store(path string, grid *statepb.Grid, generation int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &cacheEntry{
+		path:       path,
+		grid:       grid,
+		generation: generation,
+	}
+	if c.ttl > 0 {
+		entry.expires = time.Now().Add(c.ttl)
+	}
+
+	if elem, ok := c.index[path]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	c.index[path] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		if oldest == nil {
+			break
+		}
+		c.lru.Remove(oldest)
+		delete(c.index, oldest.Value.(*cacheEntry).path)
+	}
+}
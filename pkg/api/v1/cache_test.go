@@ -0,0 +1,127 @@
+/*
+Copyright 2021 The TestGrid Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	statepb "github.com/GoogleCloudPlatform/testgrid/pb/state"
+)
+
+func countingFetch(calls *int, generation int64) func(context.Context) (*statepb.Grid, int64, error) {
+	return func(context.Context) (*statepb.Grid, int64, error) {
+		*calls++
+		return &statepb.Grid{}, generation, nil
+	}
+}
+
+func TestGridCacheHitAvoidsRefetch(t *testing.T) {
+	c := NewGridCache(10, time.Minute)
+	var calls int
+	fetch := countingFetch(&calls, 1)
+
+	if _, _, err := c.Get(context.Background(), "path", fetch); err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	if _, _, err := c.Get(context.Background(), "path", fetch); err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("fetch called %d times, want 1 (second Get should hit cache)", calls)
+	}
+}
+
+func TestGridCacheZeroTTLNeverCaches(t *testing.T) {
+	c := NewGridCache(10, 0)
+	var calls int
+	fetch := countingFetch(&calls, 1)
+
+	for i := 0; i < 3; i++ {
+		if _, _, err := c.Get(context.Background(), "path", fetch); err != nil {
+			t.Fatalf("Get() = %v", err)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("fetch called %d times, want 3 (ttl=0 must disable caching, not cache forever)", calls)
+	}
+}
+
+func TestGridCacheZeroSizeNeverCaches(t *testing.T) {
+	c := NewGridCache(0, time.Minute)
+	var calls int
+	fetch := countingFetch(&calls, 1)
+
+	for i := 0; i < 3; i++ {
+		if _, _, err := c.Get(context.Background(), "path", fetch); err != nil {
+			t.Fatalf("Get() = %v", err)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("fetch called %d times, want 3 (size=0 must disable caching)", calls)
+	}
+}
+
+func TestGridCacheExpires(t *testing.T) {
+	c := NewGridCache(10, time.Millisecond)
+	var calls int
+	fetch := countingFetch(&calls, 1)
+
+	if _, _, err := c.Get(context.Background(), "path", fetch); err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, _, err := c.Get(context.Background(), "path", fetch); err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("fetch called %d times, want 2 (entry should have expired)", calls)
+	}
+}
+
+func TestGridCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewGridCache(2, time.Minute)
+	var calls int
+
+	c.Get(context.Background(), "a", countingFetch(&calls, 1))
+	c.Get(context.Background(), "b", countingFetch(&calls, 2))
+	c.Get(context.Background(), "a", countingFetch(&calls, 1)) // touch "a", making "b" the LRU entry
+	c.Get(context.Background(), "c", countingFetch(&calls, 3)) // evicts "b"
+
+	calls = 0
+	if _, _, err := c.Get(context.Background(), "a", countingFetch(&calls, 1)); err != nil {
+		t.Fatalf("Get(a) = %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("Get(a) re-fetched, want cache hit")
+	}
+
+	calls = 0
+	if _, _, err := c.Get(context.Background(), "b", countingFetch(&calls, 2)); err != nil {
+		t.Fatalf("Get(b) = %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Get(b) fetch called %d times, want 1 (b should have been evicted)", calls)
+	}
+}
+
+func TestGridETag(t *testing.T) {
+	if got, want := gridETag(42), `"gen-42"`; got != want {
+		t.Errorf("gridETag(42) = %q, want %q", got, want)
+	}
+}
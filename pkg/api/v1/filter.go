@@ -0,0 +1,317 @@
+/*
+Copyright 2021 The TestGrid Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+
+	apipb "github.com/GoogleCloudPlatform/testgrid/pb/api/v1"
+	statepb "github.com/GoogleCloudPlatform/testgrid/pb/state"
+)
+
+// defaultPageSize and maxPageSize bound how many rows ListRows returns per
+// call when the caller doesn't specify, or asks for too many.
+const (
+	defaultPageSize = 500
+	maxPageSize     = 5000
+)
+
+// rowFilter captures the subset of a ListRowsRequest that narrows which rows
+// and cells are returned, compiled once per call.
+type rowFilter struct {
+	nameRE     *regexp.Regexp
+	statuses   map[statepb.Row_Result]bool
+	minBuild   int64
+	maxBuild   int64
+	minStarted float64
+	maxStarted float64
+	hasBuild   bool
+	hasStarted bool
+}
+
+// newRowFilter compiles the filters present on req. A row name filter may be
+// either a glob (no regex metacharacters) or a regular expression; a glob
+// like "go-*-test" is translated to an anchored regexp so the common case
+// doesn't require callers to write one.
+func newRowFilter(req *apipb.ListRowsRequest) (*rowFilter, error) {
+	rf := &rowFilter{}
+
+	if pattern := req.GetRowFilter(); pattern != "" {
+		expr := pattern
+		if !strings.ContainsAny(pattern, `.+()[]{}^$|\`) {
+			expr = globToRegexp(pattern)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("row_filter: %w", err)
+		}
+		rf.nameRE = re
+	}
+
+	if statuses := req.GetStatusFilter(); len(statuses) > 0 {
+		rf.statuses = make(map[statepb.Row_Result]bool, len(statuses))
+		for _, st := range statuses {
+			rf.statuses[st] = true
+		}
+	}
+
+	if req.GetMinBuildNumber() != 0 || req.GetMaxBuildNumber() != 0 {
+		rf.hasBuild = true
+		rf.minBuild = req.GetMinBuildNumber()
+		rf.maxBuild = req.GetMaxBuildNumber()
+		if rf.maxBuild == 0 {
+			rf.maxBuild = math.MaxInt64
+		}
+	}
+
+	if req.GetMinStartTime() != 0 || req.GetMaxStartTime() != 0 {
+		rf.hasStarted = true
+		rf.minStarted = req.GetMinStartTime()
+		rf.maxStarted = req.GetMaxStartTime()
+		if rf.maxStarted == 0 {
+			rf.maxStarted = math.MaxFloat64
+		}
+	}
+
+	return rf, nil
+}
+
+// newHeaderFilter builds the rowFilter ListHeaders and its streaming
+// equivalents use to narrow the column window: headers have no row name or
+// status to filter on, only the build-number/start-time range newRowFilter
+// also honors, so this only ever populates those fields.
+func newHeaderFilter(req *apipb.ListHeadersRequest) *rowFilter {
+	rf := &rowFilter{
+		hasBuild:   req.GetMinBuildNumber() != 0 || req.GetMaxBuildNumber() != 0,
+		minBuild:   req.GetMinBuildNumber(),
+		maxBuild:   req.GetMaxBuildNumber(),
+		hasStarted: req.GetMinStartTime() != 0 || req.GetMaxStartTime() != 0,
+		minStarted: req.GetMinStartTime(),
+		maxStarted: req.GetMaxStartTime(),
+	}
+	if rf.hasBuild && rf.maxBuild == 0 {
+		rf.maxBuild = math.MaxInt64
+	}
+	if rf.hasStarted && rf.maxStarted == 0 {
+		rf.maxStarted = math.MaxFloat64
+	}
+	return rf
+}
+
+// globToRegexp converts a shell-style glob into an anchored regular
+// expression, the cheap path most callers take instead of writing a full
+// regex for "only rows under this prefix".
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteByte('^')
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteByte('$')
+	return b.String()
+}
+
+// matchesRow reports whether gRow passes the name and status filters. Status
+// is evaluated against the row's decoded results so a row is kept if any of
+// its cells match.
+func (rf *rowFilter) matchesRow(gRow *statepb.Row, decodedResults []int32) bool {
+	if rf == nil {
+		return true
+	}
+	if rf.nameRE != nil && !rf.nameRE.MatchString(gRow.Name) {
+		return false
+	}
+	if rf.statuses != nil {
+		var anyMatch bool
+		for _, result := range decodedResults {
+			if rf.statuses[statepb.Row_Result(result)] {
+				anyMatch = true
+				break
+			}
+		}
+		if !anyMatch {
+			return false
+		}
+	}
+	return true
+}
+
+// columnWindow returns the [start, end) column indices of grid.Columns that
+// satisfy the request's build-number and start-time filters, so callers can
+// skip decoding RLE runs outside the window entirely instead of decoding the
+// full grid and discarding columns afterward.
+func (rf *rowFilter) columnWindow(columns []*statepb.Column) (int, int) {
+	if rf == nil || (!rf.hasBuild && !rf.hasStarted) {
+		return 0, len(columns)
+	}
+	start, end := len(columns), 0
+	for i, col := range columns {
+		if rf.hasBuild {
+			build, err := strconv.ParseInt(col.Build, 10, 64)
+			if err == nil && (build < rf.minBuild || build > rf.maxBuild) {
+				continue
+			}
+		}
+		if rf.hasStarted && (col.Started < rf.minStarted || col.Started > rf.maxStarted) {
+			continue
+		}
+		if i < start {
+			start = i
+		}
+		if i+1 > end {
+			end = i + 1
+		}
+	}
+	if start > end {
+		return 0, 0
+	}
+	return start, end
+}
+
+// pageToken encodes/decodes the opaque cursor handed back by ListRows: the
+// index of the next row to read. Rows are returned in the stable order the
+// underlying grid already stores them in, so a numeric offset is sufficient
+// and survives across requests as long as the grid hasn't changed shape.
+func decodePageToken(token string) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+	raw, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, fmt.Errorf("malformed page_token: %w", err)
+	}
+	idx, err := strconv.Atoi(string(raw))
+	if err != nil || idx < 0 {
+		return 0, fmt.Errorf("malformed page_token: %w", err)
+	}
+	return idx, nil
+}
+
+func encodePageToken(idx int) string {
+	return base64.URLEncoding.EncodeToString([]byte(strconv.Itoa(idx)))
+}
+
+// clampPageSize applies the request's page_size, falling back to
+// defaultPageSize and capping at maxPageSize so a caller can't force the
+// server to buffer an unbounded response in one page.
+func clampPageSize(requested int32) int {
+	switch {
+	case requested <= 0:
+		return defaultPageSize
+	case int(requested) > maxPageSize:
+		return maxPageSize
+	default:
+		return int(requested)
+	}
+}
+
+// applyFieldMask clears the optional fields of resp that mask doesn't name:
+// "cells.message", "cells.icon", "issues" and "alert". This covers the common
+// case callers use a mask for: dropping the relatively heavy Message/Icon
+// strings when only Result is needed. A row's name and each cell's result and
+// cell_id are always kept. An empty or nil mask is a no-op, returning the full
+// response.
+func applyFieldMask(resp *apipb.ListRowsResponse, mask *fieldmaskpb.FieldMask) {
+	if mask == nil || len(mask.GetPaths()) == 0 {
+		return
+	}
+	keep := make(map[string]bool, len(mask.GetPaths()))
+	for _, p := range mask.GetPaths() {
+		keep[p] = true
+	}
+	wantMessage := keep["cells.message"]
+	wantIcon := keep["cells.icon"]
+	wantIssues := keep["issues"]
+	wantAlert := keep["alert"]
+	for _, row := range resp.Rows {
+		if !wantIssues {
+			row.Issues = nil
+		}
+		if !wantAlert {
+			row.Alert = nil
+		}
+		for _, cell := range row.Cells {
+			if !wantMessage {
+				cell.Message = ""
+			}
+			if !wantIcon {
+				cell.Icon = ""
+			}
+		}
+	}
+}
+
+// queryInt64 parses url query parameter name as an int64, returning 0 if it
+// is absent or malformed; these filters are all optional, so a bad value is
+// treated the same as an unset one rather than failing the request.
+func queryInt64(query url.Values, name string) int64 {
+	v, err := strconv.ParseInt(query.Get(name), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// queryFloat64 parses url query parameter name as a float64, mirroring
+// queryInt64.
+func queryFloat64(query url.Values, name string) float64 {
+	v, err := strconv.ParseFloat(query.Get(name), 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// queryStatuses parses a comma-separated list of statepb.Row_Result names
+// (e.g. "FAIL,FLAKY") from a query parameter into their enum values,
+// skipping any name that doesn't match a known result.
+func queryStatuses(raw string) []statepb.Row_Result {
+	if raw == "" {
+		return nil
+	}
+	var statuses []statepb.Row_Result
+	for _, name := range strings.Split(raw, ",") {
+		if v, ok := statepb.Row_Result_value[strings.TrimSpace(name)]; ok {
+			statuses = append(statuses, statepb.Row_Result(v))
+		}
+	}
+	return statuses
+}
+
+// queryFieldMask parses a comma-separated list of field paths from a query
+// parameter into a FieldMask, or returns nil if raw is empty.
+func queryFieldMask(raw string) *fieldmaskpb.FieldMask {
+	if raw == "" {
+		return nil
+	}
+	return &fieldmaskpb.FieldMask{Paths: strings.Split(raw, ",")}
+}
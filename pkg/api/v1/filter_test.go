@@ -0,0 +1,169 @@
+/*
+Copyright 2021 The TestGrid Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/base64"
+	"regexp"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+
+	apipb "github.com/GoogleCloudPlatform/testgrid/pb/api/v1"
+	statepb "github.com/GoogleCloudPlatform/testgrid/pb/state"
+)
+
+func TestPageTokenRoundTrip(t *testing.T) {
+	for _, idx := range []int{0, 1, 500, 123456} {
+		token := encodePageToken(idx)
+		got, err := decodePageToken(token)
+		if err != nil {
+			t.Fatalf("decodePageToken(%q) = %v", token, err)
+		}
+		if got != idx {
+			t.Errorf("decodePageToken(encodePageToken(%d)) = %d, want %d", idx, got, idx)
+		}
+	}
+}
+
+func TestDecodePageToken(t *testing.T) {
+	if got, err := decodePageToken(""); err != nil || got != 0 {
+		t.Errorf("decodePageToken(\"\") = (%d, %v), want (0, nil)", got, err)
+	}
+	if _, err := decodePageToken("not-base64!!"); err == nil {
+		t.Error("decodePageToken(malformed) = nil error, want error")
+	}
+	negative := base64.URLEncoding.EncodeToString([]byte("-1"))
+	if _, err := decodePageToken(negative); err == nil {
+		t.Error("decodePageToken(negative index) = nil error, want error")
+	}
+}
+
+func TestGlobToRegexp(t *testing.T) {
+	cases := []struct {
+		glob    string
+		match   string
+		nomatch string
+	}{
+		{"go-*-test", "go-build-test", "go-build"},
+		{"exact", "exact", "exactly"},
+		{"a?c", "abc", "ac"},
+	}
+	for _, tc := range cases {
+		re := regexp.MustCompile(globToRegexp(tc.glob))
+		if !re.MatchString(tc.match) {
+			t.Errorf("globToRegexp(%q) didn't match %q", tc.glob, tc.match)
+		}
+		if re.MatchString(tc.nomatch) {
+			t.Errorf("globToRegexp(%q) matched %q, want no match", tc.glob, tc.nomatch)
+		}
+	}
+}
+
+func TestColumnWindow(t *testing.T) {
+	columns := []*statepb.Column{
+		{Build: "1", Started: 100},
+		{Build: "2", Started: 200},
+		{Build: "3", Started: 300},
+		{Build: "4", Started: 400},
+	}
+
+	t.Run("no filter returns everything", func(t *testing.T) {
+		rf := &rowFilter{}
+		start, end := rf.columnWindow(columns)
+		if start != 0 || end != len(columns) {
+			t.Errorf("columnWindow() = (%d, %d), want (0, %d)", start, end, len(columns))
+		}
+	})
+
+	t.Run("build range", func(t *testing.T) {
+		rf := &rowFilter{hasBuild: true, minBuild: 2, maxBuild: 3}
+		start, end := rf.columnWindow(columns)
+		if start != 1 || end != 3 {
+			t.Errorf("columnWindow() = (%d, %d), want (1, 3)", start, end)
+		}
+	})
+
+	t.Run("no columns match", func(t *testing.T) {
+		rf := &rowFilter{hasBuild: true, minBuild: 100, maxBuild: 200}
+		start, end := rf.columnWindow(columns)
+		if start != 0 || end != 0 {
+			t.Errorf("columnWindow() = (%d, %d), want (0, 0)", start, end)
+		}
+	})
+}
+
+func TestNewHeaderFilterColumnWindow(t *testing.T) {
+	columns := []*statepb.Column{
+		{Build: "1", Started: 100},
+		{Build: "2", Started: 200},
+		{Build: "3", Started: 300},
+		{Build: "4", Started: 400},
+	}
+
+	rf := newHeaderFilter(&apipb.ListHeadersRequest{MinBuildNumber: 2, MaxBuildNumber: 3})
+	start, end := rf.columnWindow(columns)
+	if start != 1 || end != 3 {
+		t.Errorf("columnWindow() = (%d, %d), want (1, 3)", start, end)
+	}
+
+	if rf := newHeaderFilter(&apipb.ListHeadersRequest{}); rf.hasBuild || rf.hasStarted {
+		t.Errorf("newHeaderFilter(empty) = %+v, want no build/start filter", rf)
+	}
+}
+
+func TestApplyFieldMask(t *testing.T) {
+	resp := &apipb.ListRowsResponse{
+		Rows: []*apipb.ListRowsResponse_Row{{
+			Name:   "row",
+			Issues: []string{"123"},
+			Alert:  &statepb.AlertInfo{},
+			Cells: []*apipb.ListRowsResponse_Cell{{
+				Message: "failed",
+				Icon:    "X",
+			}},
+		}},
+	}
+
+	applyFieldMask(resp, &fieldmaskpb.FieldMask{Paths: []string{"cells.message"}})
+
+	row := resp.Rows[0]
+	if row.Cells[0].Message != "failed" {
+		t.Errorf("Message = %q, want preserved", row.Cells[0].Message)
+	}
+	if row.Cells[0].Icon != "" {
+		t.Errorf("Icon = %q, want cleared", row.Cells[0].Icon)
+	}
+	if row.Issues != nil {
+		t.Errorf("Issues = %v, want cleared", row.Issues)
+	}
+	if row.Alert != nil {
+		t.Errorf("Alert = %v, want cleared", row.Alert)
+	}
+}
+
+func TestApplyFieldMaskNoOp(t *testing.T) {
+	resp := &apipb.ListRowsResponse{
+		Rows: []*apipb.ListRowsResponse_Row{{
+			Cells: []*apipb.ListRowsResponse_Cell{{Message: "failed", Icon: "X"}},
+		}},
+	}
+	applyFieldMask(resp, nil)
+	if resp.Rows[0].Cells[0].Message != "failed" || resp.Rows[0].Cells[0].Icon != "X" {
+		t.Error("applyFieldMask(nil) modified the response, want no-op")
+	}
+}
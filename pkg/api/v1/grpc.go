@@ -0,0 +1,170 @@
+/*
+Copyright 2021 The TestGrid Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	apipb "github.com/GoogleCloudPlatform/testgrid/pb/api/v1"
+	"github.com/GoogleCloudPlatform/testgrid/pkg/api/v1/transform"
+)
+
+// RegisterGRPC registers s as the implementation of apipb.TestGridServiceServer
+// on grpcServer, so the same Server backs both the HTTP/JSON handlers in this
+// package and the gRPC surface. Server satisfies the interface directly via
+// its ListRows and ListHeaders methods in state.go; those methods translate
+// their errors into gRPC status codes themselves so this file doesn't need
+// wrapper methods to do it.
+func RegisterGRPC(grpcServer *grpc.Server, s *Server) {
+	apipb.RegisterTestGridServiceServer(grpcServer, s)
+}
+
+// ListRowsStream implements the server-streaming half of
+// apipb.TestGridServiceServer: it decodes and emits one row at a time instead
+// of building a complete ListRowsResponse, so a client paging through a grid
+// with tens of thousands of rows never forces the server to hold the whole
+// response in memory at once. It honors the same row/status filter, column
+// window, and transform that ListRows and ListRowsStreamHTTP apply, so a
+// streaming client that sets RowFilter/StatusFilter/Transform gets filtered
+// results instead of the unfiltered grid.
+func (s *Server) ListRowsStream(req *apipb.ListRowsRequest, stream apipb.TestGridService_ListRowsStreamServer) error {
+	ctx, cancel := context.WithTimeout(stream.Context(), s.Timeout)
+	defer cancel()
+
+	cfg, err := s.getConfig(ctx, logrus.WithContext(ctx), req.GetScope())
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	cfg.Mutex.RLock()
+	defer cfg.Mutex.RUnlock()
+
+	grid, _, err := s.resolveGrid(ctx, cfg, req.GetScope(), req.GetDashboard(), req.GetTab())
+	if err != nil {
+		return status.Error(codes.NotFound, err.Error())
+	}
+
+	rf, err := newRowFilter(req)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	colStart, colEnd := rf.columnWindow(grid.Columns)
+
+	var prog *transform.Program
+	var budget *transform.Budget
+	if expr := req.GetTransform(); expr != "" {
+		prog, err = transform.Compile(expr)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		budget = transform.NewBudget(transform.DefaultRequestBudget)
+	}
+
+	for _, gRow := range grid.Rows {
+		if err := ctx.Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		gRowDecodedResults := decodeRLEWindow(gRow.Results, colStart, colEnd)
+		if !rf.matchesRow(gRow, gRowDecodedResults) {
+			continue
+		}
+
+		cellsCount := len(gRowDecodedResults)
+		row := apipb.ListRowsResponse_Row{
+			Name:   gRow.Name,
+			Issues: gRow.Issues,
+			Alert:  gRow.AlertInfo,
+			Cells:  make([]*apipb.ListRowsResponse_Cell, 0, cellsCount),
+		}
+		for cellIdx := 0; cellIdx < cellsCount; cellIdx++ {
+			row.Cells = append(row.Cells, &apipb.ListRowsResponse_Cell{
+				Result:  gRowDecodedResults[cellIdx],
+				CellId:  gRow.CellIds[colStart+cellIdx],
+				Message: gRow.Messages[colStart+cellIdx],
+				Icon:    gRow.Icons[colStart+cellIdx],
+			})
+		}
+
+		if prog != nil {
+			keep, err := applyTransform(prog, &row, budget)
+			if err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+			if !keep {
+				continue
+			}
+		}
+
+		if err := stream.Send(&row); err != nil {
+			return fmt.Errorf("send row %q: %w", row.Name, err)
+		}
+	}
+	return nil
+}
+
+// ListHeadersStream implements the server-streaming half of
+// apipb.TestGridServiceServer, emitting one column header at a time within
+// the same build-number/start-time window ListHeaders applies.
+func (s *Server) ListHeadersStream(req *apipb.ListHeadersRequest, stream apipb.TestGridService_ListHeadersStreamServer) error {
+	ctx, cancel := context.WithTimeout(stream.Context(), s.Timeout)
+	defer cancel()
+
+	cfg, err := s.getConfig(ctx, logrus.WithContext(ctx), req.GetScope())
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	cfg.Mutex.RLock()
+	defer cfg.Mutex.RUnlock()
+
+	grid, _, err := s.resolveGrid(ctx, cfg, req.GetScope(), req.GetDashboard(), req.GetTab())
+	if err != nil {
+		return status.Error(codes.NotFound, err.Error())
+	}
+
+	rf := newHeaderFilter(req)
+	start, end := rf.columnWindow(grid.Columns)
+
+	for _, gColumn := range grid.Columns[start:end] {
+		if err := ctx.Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		millis := gColumn.Started
+		sec := millis / 1000
+		nanos := math.Mod(millis, 1000) * 1e6
+		header := apipb.ListHeadersResponse_Header{
+			Name:  gColumn.Name,
+			Build: gColumn.Build,
+			Started: &timestamp.Timestamp{
+				Seconds: int64(sec),
+				Nanos:   int32(nanos),
+			},
+			Extra:      gColumn.Extra,
+			HotlistIds: gColumn.HotlistIds,
+		}
+		if err := stream.Send(&header); err != nil {
+			return fmt.Errorf("send header %q: %w", header.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2021 The TestGrid Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	apipb "github.com/GoogleCloudPlatform/testgrid/pb/api/v1"
+	statepb "github.com/GoogleCloudPlatform/testgrid/pb/state"
+	fakegcs "github.com/GoogleCloudPlatform/testgrid/util/gcs/fake"
+)
+
+// fakeRowStream implements apipb.TestGridService_ListRowsStreamServer by
+// collecting every sent row, so tests can assert on ListRowsStream's output
+// without a real gRPC connection.
+type fakeRowStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	rows []*apipb.ListRowsResponse_Row
+}
+
+func (f *fakeRowStream) Context() context.Context { return f.ctx }
+
+func (f *fakeRowStream) Send(row *apipb.ListRowsResponse_Row) error {
+	f.rows = append(f.rows, row)
+	return nil
+}
+
+// fakeHeaderStream is fakeRowStream's equivalent for ListHeadersStream.
+type fakeHeaderStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	headers []*apipb.ListHeadersResponse_Header
+}
+
+func (f *fakeHeaderStream) Context() context.Context { return f.ctx }
+
+func (f *fakeHeaderStream) Send(header *apipb.ListHeadersResponse_Header) error {
+	f.headers = append(f.headers, header)
+	return nil
+}
+
+// newGRPCTestServer builds a Server backed by an in-memory GCS fake seeded
+// with a grid wide enough (four columns, one passing row and one
+// consistently failing row) to exercise column-window and row-status
+// filtering.
+func newGRPCTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	client := fakegcs.NewClient()
+	grid := &statepb.Grid{
+		Columns: []*statepb.Column{
+			{Build: "1", Name: "1", Started: 100},
+			{Build: "2", Name: "2", Started: 200},
+			{Build: "3", Name: "3", Started: 300},
+			{Build: "4", Name: "4", Started: 400},
+		},
+		Rows: []*statepb.Row{
+			{
+				Name:     "//passing-row",
+				Results:  []int32{int32(statepb.Row_PASS), 4},
+				CellIds:  []string{"1", "2", "3", "4"},
+				Messages: []string{"", "", "", ""},
+				Icons:    []string{"", "", "", ""},
+			},
+			{
+				Name:     "//failing-row",
+				Results:  []int32{int32(statepb.Row_FAIL), 4},
+				CellIds:  []string{"1", "2", "3", "4"},
+				Messages: []string{"m1", "m2", "m3", "m4"},
+				Icons:    []string{"", "", "", ""},
+			},
+		},
+	}
+	if err := fakegcs.SeedTestGrid(client, "dashboard", "tab", grid); err != nil {
+		t.Fatalf("seed fixture grid: %v", err)
+	}
+
+	return &Server{
+		Client:         client,
+		GridPathPrefix: "grid",
+		TabPathPrefix:  "tabs",
+		Timeout:        10 * time.Second,
+		Cache:          NewGridCache(0, 0),
+	}
+}
+
+// TestListRowsStreamMatchesListRows asserts that ListRowsStream applies the
+// same status filter and column window as the unary ListRows, instead of
+// unconditionally streaming every row and every column like the pre-fix
+// implementation did.
+func TestListRowsStreamMatchesListRows(t *testing.T) {
+	s := newGRPCTestServer(t)
+	req := &apipb.ListRowsRequest{
+		Dashboard:      "dashboard",
+		Tab:            "tab",
+		StatusFilter:   []statepb.Row_Result{statepb.Row_FAIL},
+		MinBuildNumber: 2,
+		MaxBuildNumber: 3,
+	}
+
+	want, err := s.ListRows(context.Background(), req)
+	if err != nil {
+		t.Fatalf("ListRows() = %v", err)
+	}
+
+	stream := &fakeRowStream{ctx: context.Background()}
+	if err := s.ListRowsStream(req, stream); err != nil {
+		t.Fatalf("ListRowsStream() = %v", err)
+	}
+
+	if len(stream.rows) != len(want.Rows) {
+		t.Fatalf("ListRowsStream() sent %d rows, want %d", len(stream.rows), len(want.Rows))
+	}
+	for i, row := range stream.rows {
+		if row.Name != want.Rows[i].Name {
+			t.Errorf("row %d name = %q, want %q", i, row.Name, want.Rows[i].Name)
+		}
+		if len(row.Cells) != len(want.Rows[i].Cells) {
+			t.Errorf("row %d has %d cells, want %d (column window not applied)", i, len(row.Cells), len(want.Rows[i].Cells))
+		}
+	}
+}
+
+// TestListHeadersStreamMatchesListHeaders asserts ListHeadersStream applies
+// the same build-number column window as the unary ListHeaders.
+func TestListHeadersStreamMatchesListHeaders(t *testing.T) {
+	s := newGRPCTestServer(t)
+	req := &apipb.ListHeadersRequest{
+		Dashboard:      "dashboard",
+		Tab:            "tab",
+		MinBuildNumber: 2,
+		MaxBuildNumber: 3,
+	}
+
+	want, err := s.ListHeaders(context.Background(), req)
+	if err != nil {
+		t.Fatalf("ListHeaders() = %v", err)
+	}
+
+	stream := &fakeHeaderStream{ctx: context.Background()}
+	if err := s.ListHeadersStream(req, stream); err != nil {
+		t.Fatalf("ListHeadersStream() = %v", err)
+	}
+
+	if len(stream.headers) != len(want.Headers) {
+		t.Fatalf("ListHeadersStream() sent %d headers, want %d", len(stream.headers), len(want.Headers))
+	}
+	for i, header := range stream.headers {
+		if header.Build != want.Headers[i].Build {
+			t.Errorf("header %d build = %q, want %q", i, header.Build, want.Headers[i].Build)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2021 The TestGrid Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer is the package-wide OpenTelemetry tracer for API handler spans.
+var tracer = otel.Tracer("github.com/GoogleCloudPlatform/testgrid/pkg/api/v1")
+
+var (
+	apiRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "testgrid_api_requests_total",
+		Help: "Count of TestGrid API requests by endpoint and status.",
+	}, []string{"endpoint", "status"})
+
+	apiGridBytes = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "testgrid_api_grid_bytes",
+		Help:    "Size in bytes of the downloaded tab-state grid proto.",
+		Buckets: prometheus.ExponentialBuckets(1<<10, 4, 10), // 1KiB .. ~256MiB
+	})
+
+	apiRLEDecodeSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "testgrid_api_rle_decode_seconds",
+		Help:    "Time spent decoding run-length-encoded row results.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	apiGridDownloadSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "testgrid_api_grid_download_seconds",
+		Help:    "Time spent downloading the tab-state grid proto from GCS.",
+		Buckets: prometheus.DefBuckets,
+	})
+)
+
+// statusLabel maps an error into the low-cardinality status label used by
+// apiRequestsTotal, so handlers don't leak arbitrary error text into metric
+// label values.
+func statusLabel(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	return "error"
+}
+
+// observeRequest records a completed call to one of the v1 API endpoints.
+// scope is deliberately not a label here: it comes straight from the
+// unauthenticated scope query parameter, and labeling on it would let any
+// caller mint unbounded new time series by varying ?scope=.
+func observeRequest(endpoint string, err error) {
+	apiRequestsTotal.WithLabelValues(endpoint, statusLabel(err)).Inc()
+}
+
+// startSpan starts an OpenTelemetry span for an API handler stage, recording
+// the given attributes.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
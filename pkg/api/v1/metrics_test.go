@@ -0,0 +1,52 @@
+/*
+Copyright 2021 The TestGrid Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestStatusLabel(t *testing.T) {
+	if got := statusLabel(nil); got != "ok" {
+		t.Errorf("statusLabel(nil) = %q, want %q", got, "ok")
+	}
+	if got := statusLabel(errors.New("boom")); got != "error" {
+		t.Errorf("statusLabel(err) = %q, want %q", got, "error")
+	}
+}
+
+// TestObserveRequestHasNoScopeLabel guards against scope, an unauthenticated
+// and unbounded query parameter, coming back as a metric label: that would
+// let any caller mint unbounded new time series by varying ?scope=.
+func TestObserveRequestHasNoScopeLabel(t *testing.T) {
+	apiRequestsTotal.Reset()
+	observeRequest("ListRows", nil)
+
+	if got := testutil.CollectAndCount(apiRequestsTotal); got != 1 {
+		t.Fatalf("apiRequestsTotal has %d series after one call, want 1", got)
+	}
+
+	for i := 0; i < 5; i++ {
+		observeRequest("ListRows", nil)
+	}
+	if got := testutil.CollectAndCount(apiRequestsTotal); got != 1 {
+		t.Errorf("apiRequestsTotal has %d series after repeated identical calls, want 1 (scope must not be a label)", got)
+	}
+}
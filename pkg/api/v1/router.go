@@ -0,0 +1,45 @@
+/*
+Copyright 2021 The TestGrid Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// scopeParam is the query parameter every v1 HTTP handler reads the request's
+// scope from.
+const scopeParam = "scope"
+
+// Router registers every v1 API HTTP handler, including the streaming
+// variants, onto router against s.
+func Router(router *mux.Router, s *Server) {
+	router.HandleFunc("/dashboards/{dashboard}/tabs/{tab}/headers", s.ListHeadersHTTP).Methods(http.MethodGet)
+	router.HandleFunc("/dashboards/{dashboard}/tabs/{tab}/rows", s.ListRowsHTTP).Methods(http.MethodGet)
+	router.HandleFunc("/dashboards/{dashboard}/tabs/{tab}/headers/stream", s.ListHeadersStreamHTTP).Methods(http.MethodGet)
+	router.HandleFunc("/dashboards/{dashboard}/tabs/{tab}/rows/stream", s.ListRowsStreamHTTP).Methods(http.MethodGet)
+}
+
+// writeJSON marshals v to w as a JSON response body.
+func (s Server) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -24,14 +24,19 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"time"
 
 	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/GoogleCloudPlatform/testgrid/config"
 	apipb "github.com/GoogleCloudPlatform/testgrid/pb/api/v1"
 	statepb "github.com/GoogleCloudPlatform/testgrid/pb/state"
+	"github.com/GoogleCloudPlatform/testgrid/pkg/api/v1/transform"
 	"github.com/GoogleCloudPlatform/testgrid/pkg/tabulator"
 	"github.com/GoogleCloudPlatform/testgrid/util/gcs"
 )
@@ -63,78 +68,206 @@ func findDashboardTab(cfg *cachedConfig, dashboardInput string, tabInput string)
 	return dashboardName, tabName, "", fmt.Errorf("Test group not found")
 }
 
-// GroupGrid fetch tab group name grid info (columns, rows, ..etc)
-func (s Server) GroupGrid(ctx context.Context, configPath *gcs.Path, groupName string) (*statepb.Grid, error) {
+// GroupGrid fetch tab group name grid info (columns, rows, ..etc), along with
+// the GCS generation it was read at.
+func (s Server) GroupGrid(ctx context.Context, configPath *gcs.Path, groupName string) (*statepb.Grid, int64, error) {
+	ctx, span := startSpan(ctx, "api.GroupGrid", attribute.String("group", groupName))
+	defer span.End()
+
 	groupPath, err := configPath.ResolveReference(&url.URL{Path: path.Join(s.GridPathPrefix, groupName)})
 	if err != nil {
-		return nil, fmt.Errorf("resolve: %v", err)
+		return nil, 0, fmt.Errorf("resolve: %v", err)
 	}
-	grid, _, err := gcs.DownloadGrid(ctx, s.Client, *groupPath)
+
+	grid, generation, err := s.Cache.Get(ctx, groupPath.String(), func(ctx context.Context) (*statepb.Grid, int64, error) {
+		start := time.Now()
+		grid, attrs, err := gcs.DownloadGrid(ctx, s.Client, *groupPath)
+		apiGridDownloadSeconds.Observe(time.Since(start).Seconds())
+		if err != nil {
+			return nil, 0, fmt.Errorf("load: %w", err)
+		}
+		var generation int64
+		if attrs != nil {
+			apiGridBytes.Observe(float64(attrs.Size))
+			generation = attrs.Generation
+		}
+		return grid, generation, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("load: %w", err)
+		span.RecordError(err)
+		return nil, 0, err
 	}
-	return grid, err
+	return grid, generation, nil
 }
 
-// Grid fetch tab and grid info (columns, rows, ..etc)
-func (s Server) Grid(ctx context.Context, scope string, dashboardName, tabName, testGroupNanme string) (*statepb.Grid, error) {
+// Grid fetch tab and grid info (columns, rows, ..etc), along with the GCS
+// generation it was read at.
+func (s Server) Grid(ctx context.Context, scope string, dashboardName, tabName, testGroupNanme string) (*statepb.Grid, int64, error) {
+	ctx, span := startSpan(ctx, "api.Grid", attribute.String("dashboard", dashboardName), attribute.String("tab", tabName))
+	defer span.End()
+
 	configPath, _, err := s.configPath(scope)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	if s.TabPathPrefix == "" { // TODO(chases2): Delete; all APIs should be configured to use Tabulator now
 		return s.GroupGrid(ctx, configPath, testGroupNanme)
 	}
-	path, err := tabulator.TabStatePath(*configPath, s.TabPathPrefix, dashboardName, tabName)
+	tabPath, err := tabulator.TabStatePath(*configPath, s.TabPathPrefix, dashboardName, tabName)
+	if err != nil {
+		return nil, 0, fmt.Errorf("tab state path: %v", err)
+	}
+
+	grid, generation, err := s.Cache.Get(ctx, tabPath.String(), func(ctx context.Context) (*statepb.Grid, int64, error) {
+		start := time.Now()
+		grid, attrs, err := gcs.DownloadGrid(ctx, s.Client, *tabPath)
+		apiGridDownloadSeconds.Observe(time.Since(start).Seconds())
+		if err != nil {
+			return nil, 0, err
+		}
+		var generation int64
+		if attrs != nil {
+			apiGridBytes.Observe(float64(attrs.Size))
+			generation = attrs.Generation
+		}
+		return grid, generation, nil
+	})
+	if err != nil {
+		span.RecordError(err)
+		return nil, 0, err
+	}
+	return grid, generation, nil
+}
+
+// resolveGrid looks up the dashboard/tab's grid, along with the GCS
+// generation it was read at, going through the Server's GridCache so repeat
+// calls for the same tab don't each issue a GCS download.
+func (s *Server) resolveGrid(ctx context.Context, cfg *cachedConfig, scope, dashboardInput, tabInput string) (grid *statepb.Grid, generation int64, err error) {
+	dashboardName, tabName, testGroupName, err := findDashboardTab(cfg, dashboardInput, tabInput)
+	if err != nil {
+		return nil, 0, err
+	}
+	grid, generation, err = s.Grid(ctx, scope, dashboardName, tabName, testGroupName)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Dashboard {%q} or tab {%q} not found", dashboardInput, tabInput)
+	}
+	if grid == nil {
+		return nil, 0, errors.New("grid not found")
+	}
+	return grid, generation, nil
+}
+
+// resolveGridForHTTP resolves cfg and the dashboard/tab's grid once for an
+// HTTP handler, so the handler can answer an If-None-Match request and then
+// build the response body from the same grid instead of resolving it twice.
+// With the GridCache disabled (--grid-cache-size=0 or --grid-cache-ttl=0, see
+// GridCache.Get), a second resolve means a second GCS download per request.
+func (s *Server) resolveGridForHTTP(ctx context.Context, scope, dashboardInput, tabInput string) (*statepb.Grid, int64, error) {
+	cfg, err := s.getConfig(ctx, logrus.WithContext(ctx), scope)
 	if err != nil {
-		return nil, fmt.Errorf("tab state path: %v", err)
+		return nil, 0, err
 	}
-	grid, _, err := gcs.DownloadGrid(ctx, s.Client, *path)
-	return grid, err
+	cfg.Mutex.RLock()
+	defer cfg.Mutex.RUnlock()
+
+	return s.resolveGrid(ctx, cfg, scope, dashboardInput, tabInput)
 }
 
 // decodeRLE decodes the run length encoded data
 //   [0, 3, 5, 4] -> [0, 0, 0, 5, 5, 5, 5]
 func decodeRLE(encodedData []int32) []int32 {
+	return decodeRLEWindow(encodedData, 0, math.MaxInt32)
+}
+
+// decodeRLEWindow decodes encodedData like decodeRLE, but only materializes
+// columns in [start, end); runs entirely outside the window are skipped
+// without ever being expanded, which matters when a caller has asked for a
+// narrow column range out of a grid with a very long history.
+func decodeRLEWindow(encodedData []int32, start, end int) []int32 {
+	decodeStart := time.Now()
+	defer func() { apiRLEDecodeSeconds.Observe(time.Since(decodeStart).Seconds()) }()
+
 	var decodedResult []int32
 	encodedDataLength := len(encodedData)
-	if encodedDataLength%2 == 0 {
-		for encodedDataIdx := 0; encodedDataIdx < encodedDataLength; encodedDataIdx += 2 {
-			for cellRepeatCount := encodedData[encodedDataIdx+1]; cellRepeatCount > 0; cellRepeatCount-- {
-				decodedResult = append(decodedResult, encodedData[encodedDataIdx])
-			}
+	if encodedDataLength%2 != 0 {
+		return decodedResult
+	}
+	var col int
+	for encodedDataIdx := 0; encodedDataIdx < encodedDataLength; encodedDataIdx += 2 {
+		value := encodedData[encodedDataIdx]
+		count := int(encodedData[encodedDataIdx+1])
+		runStart := col
+		runEnd := col + count
+		col = runEnd
+		if runEnd <= start || runStart >= end {
+			continue
+		}
+		for i := max(runStart, start); i < min(runEnd, end); i++ {
+			decodedResult = append(decodedResult, value)
 		}
 	}
 	return decodedResult
 }
 
-// ListHeaders returns dashboard tab headers
-func (s *Server) ListHeaders(ctx context.Context, req *apipb.ListHeadersRequest) (*apipb.ListHeadersResponse, error) {
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// ListHeaders returns dashboard tab headers. Errors are returned as gRPC
+// status errors (NotFound for an unresolvable scope/dashboard/tab) so that
+// the gRPC registration in grpc.go, which serves this method directly,
+// surfaces a meaningful code instead of codes.Unknown.
+// ListHeadersHTTP doesn't call this method: it resolves the grid itself via
+// resolveGridForHTTP to avoid fetching it twice, then calls
+// buildHeadersResponse directly.
+func (s *Server) ListHeaders(ctx context.Context, req *apipb.ListHeadersRequest) (resp *apipb.ListHeadersResponse, err error) {
+	ctx, span := startSpan(ctx, "api.ListHeaders", attribute.String("dashboard", req.GetDashboard()), attribute.String("tab", req.GetTab()))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+		observeRequest("ListHeaders", err)
+	}()
+
 	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
 	defer cancel()
 
 	cfg, err := s.getConfig(ctx, logrus.WithContext(ctx), req.GetScope())
 	if err != nil {
-		return nil, err
+		return nil, status.Error(codes.NotFound, err.Error())
 	}
 	cfg.Mutex.RLock()
 	defer cfg.Mutex.RUnlock()
 
-	dashboardName, tabName, testGroupName, err := findDashboardTab(cfg, req.GetDashboard(), req.GetTab())
+	grid, _, err := s.resolveGrid(ctx, cfg, req.GetScope(), req.GetDashboard(), req.GetTab())
 	if err != nil {
-		return nil, err
+		return nil, status.Error(codes.NotFound, err.Error())
 	}
 
-	grid, err := s.Grid(ctx, req.GetScope(), dashboardName, tabName, testGroupName)
-	if err != nil {
-		return nil, fmt.Errorf("Dashboard {%q} or tab {%q} not found", req.GetDashboard(), req.GetTab())
-	}
-	if grid == nil {
-		return nil, errors.New("grid not found")
-	}
+	return buildHeadersResponse(grid, req), nil
+}
+
+// buildHeadersResponse applies req's build-number/start-time column window to
+// grid, producing the ListHeadersResponse. Factored out of ListHeaders so
+// ListHeadersHTTP, which has already resolved grid via resolveGridForHTTP to
+// answer the If-None-Match check, doesn't need to resolve it a second time.
+func buildHeadersResponse(grid *statepb.Grid, req *apipb.ListHeadersRequest) *apipb.ListHeadersResponse {
+	rf := newHeaderFilter(req)
+	start, end := rf.columnWindow(grid.Columns)
 
 	var dashboardTabResponse apipb.ListHeadersResponse
-	for _, gColumn := range grid.Columns {
+	for _, gColumn := range grid.Columns[start:end] {
 		// TODO(#683): Remove timestamp conversion math
 		millis := gColumn.Started
 		sec := millis / 1000
@@ -151,90 +284,248 @@ func (s *Server) ListHeaders(ctx context.Context, req *apipb.ListHeadersRequest)
 		}
 		dashboardTabResponse.Headers = append(dashboardTabResponse.Headers, &column)
 	}
-	return &dashboardTabResponse, nil
+	return &dashboardTabResponse
 }
 
 // ListHeadersHTTP returns dashboard tab headers
 // Response json: ListHeadersResponse
 func (s Server) ListHeadersHTTP(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
+	query := r.URL.Query()
 	req := apipb.ListHeadersRequest{
-		Scope:     r.URL.Query().Get(scopeParam),
-		Dashboard: vars["dashboard"],
-		Tab:       vars["tab"],
+		Scope:          query.Get(scopeParam),
+		Dashboard:      vars["dashboard"],
+		Tab:            vars["tab"],
+		MinBuildNumber: queryInt64(query, "min_build_number"),
+		MaxBuildNumber: queryInt64(query, "max_build_number"),
+		MinStartTime:   queryFloat64(query, "min_start_time"),
+		MaxStartTime:   queryFloat64(query, "max_start_time"),
 	}
-	resp, err := s.ListHeaders(r.Context(), &req)
+
+	ctx, span := startSpan(r.Context(), "api.ListHeaders", attribute.String("dashboard", req.GetDashboard()), attribute.String("tab", req.GetTab()))
+	var err error
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+		observeRequest("ListHeaders", err)
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	var grid *statepb.Grid
+	var generation int64
+	grid, generation, err = s.resolveGridForHTTP(ctx, req.GetScope(), req.GetDashboard(), req.GetTab())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
+	if checkNotModified(w, r, generation) {
+		return
+	}
 
+	resp := buildHeadersResponse(grid, &req)
 	s.writeJSON(w, &resp)
 }
 
-// ListRows returns dashboard tab rows
-func (s *Server) ListRows(ctx context.Context, req *apipb.ListRowsRequest) (*apipb.ListRowsResponse, error) {
+// ListRows returns dashboard tab rows. Like ListHeaders, it returns gRPC
+// status errors (NotFound for an unresolvable scope/dashboard/tab,
+// InvalidArgument for a malformed filter/transform/page_token, Internal for a
+// transform evaluation failure) so the gRPC surface gets a real code.
+// ListRowsHTTP doesn't call this method: it resolves the grid itself via
+// resolveGridForHTTP to avoid fetching it twice, then calls
+// buildRowsResponse directly.
+func (s *Server) ListRows(ctx context.Context, req *apipb.ListRowsRequest) (resp *apipb.ListRowsResponse, err error) {
+	ctx, span := startSpan(ctx, "api.ListRows", attribute.String("dashboard", req.GetDashboard()), attribute.String("tab", req.GetTab()))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+		observeRequest("ListRows", err)
+	}()
+
 	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
 	defer cancel()
 
 	// this should be factored out of this function
 	cfg, err := s.getConfig(ctx, logrus.WithContext(ctx), req.GetScope())
 	if err != nil {
-		return nil, err
+		return nil, status.Error(codes.NotFound, err.Error())
 	}
 	cfg.Mutex.RLock()
 	defer cfg.Mutex.RUnlock()
 
-	dashboardName, tabName, testGroupName, err := findDashboardTab(cfg, req.GetDashboard(), req.GetTab())
+	grid, _, err := s.resolveGrid(ctx, cfg, req.GetScope(), req.GetDashboard(), req.GetTab())
 	if err != nil {
-		return nil, err
+		return nil, status.Error(codes.NotFound, err.Error())
 	}
 
-	grid, err := s.Grid(ctx, req.GetScope(), dashboardName, tabName, testGroupName)
+	return buildRowsResponse(grid, req)
+}
+
+// buildRowsResponse applies req's row filter, column window, transform, and
+// pagination to grid, producing the ListRowsResponse. Factored out of
+// ListRows so ListRowsHTTP, which has already resolved grid via
+// resolveGridForHTTP to answer the If-None-Match check, doesn't need to
+// resolve it a second time.
+func buildRowsResponse(grid *statepb.Grid, req *apipb.ListRowsRequest) (*apipb.ListRowsResponse, error) {
+	rf, err := newRowFilter(req)
 	if err != nil {
-		return nil, fmt.Errorf("Dashboard {%q} or tab {%q} not found", req.GetDashboard(), req.GetTab())
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
-	if grid == nil {
-		return nil, errors.New("grid not found")
+	colStart, colEnd := rf.columnWindow(grid.Columns)
+
+	var prog *transform.Program
+	var budget *transform.Budget
+	if expr := req.GetTransform(); expr != "" {
+		prog, err = transform.Compile(expr)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		budget = transform.NewBudget(transform.DefaultRequestBudget)
+	}
+
+	offset, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
+	pageSize := clampPageSize(req.GetPageSize())
 
 	dashboardTabResponse := apipb.ListRowsResponse{
-		Rows: make([]*apipb.ListRowsResponse_Row, 0, len(grid.Rows)),
+		Rows: make([]*apipb.ListRowsResponse_Row, 0, pageSize),
 	}
+	var matched int
 	for _, gRow := range grid.Rows {
-		cellsCount := len(gRow.CellIds)
+		gRowDecodedResults := decodeRLEWindow(gRow.Results, colStart, colEnd)
+		if !rf.matchesRow(gRow, gRowDecodedResults) {
+			continue
+		}
+
+		cellsCount := len(gRowDecodedResults)
 		row := apipb.ListRowsResponse_Row{
 			Name:   gRow.Name,
 			Issues: gRow.Issues,
 			Alert:  gRow.AlertInfo,
 			Cells:  make([]*apipb.ListRowsResponse_Cell, 0, cellsCount),
 		}
-		gRowDecodedResults := decodeRLE(gRow.Results)
 		// loop through CellIds, Messages, Icons slices and build cell struct objects
 		for cellIdx := 0; cellIdx < cellsCount; cellIdx++ {
 			cell := apipb.ListRowsResponse_Cell{
 				Result:  gRowDecodedResults[cellIdx],
-				CellId:  gRow.CellIds[cellIdx],
-				Message: gRow.Messages[cellIdx],
-				Icon:    gRow.Icons[cellIdx],
+				CellId:  gRow.CellIds[colStart+cellIdx],
+				Message: gRow.Messages[colStart+cellIdx],
+				Icon:    gRow.Icons[colStart+cellIdx],
 			}
 			row.Cells = append(row.Cells, &cell)
 		}
+
+		if prog != nil {
+			keep, err := applyTransform(prog, &row, budget)
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+			if !keep {
+				continue
+			}
+		}
+
+		matched++
+		if matched <= offset {
+			continue
+		}
+		if len(dashboardTabResponse.Rows) >= pageSize {
+			dashboardTabResponse.NextPageToken = encodePageToken(matched - 1)
+			break
+		}
 		dashboardTabResponse.Rows = append(dashboardTabResponse.Rows, &row)
 	}
+
+	applyFieldMask(&dashboardTabResponse, req.GetFieldMask())
 	return &dashboardTabResponse, nil
 }
 
+// applyTransform runs prog against row: a boolean result drops the row when
+// false, while per-cell evaluation lets the expression derive a per-cell
+// value. budget caps the total number of Eval calls this (and every other)
+// call to applyTransform may spend for the current request.
+//
+// A derived value only overwrites cell.Message when the cell didn't already
+// have one; a real test-failure message is never clobbered by a computed
+// column. Surfacing a computed value alongside an existing message would
+// need a dedicated field on the Cell proto, which this package doesn't own.
+func applyTransform(prog *transform.Program, row *apipb.ListRowsResponse_Row, budget *transform.Budget) (bool, error) {
+	_, keep, err := prog.EvalRow(row, budget)
+	if err != nil {
+		return false, fmt.Errorf("transform: %w", err)
+	}
+	if !keep {
+		return false, nil
+	}
+	for _, cell := range row.Cells {
+		out, err := prog.EvalCell(row, cell, budget)
+		if err != nil {
+			return false, fmt.Errorf("transform: %w", err)
+		}
+		if cell.Message != "" {
+			continue
+		}
+		if s, ok := out.Value().(string); ok {
+			cell.Message = s
+		}
+	}
+	return true, nil
+}
+
 // ListRowsHTTP returns dashboard tab rows
 // Response json: ListRowsResponse
 func (s Server) ListRowsHTTP(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
+	query := r.URL.Query()
 	req := apipb.ListRowsRequest{
-		Scope:     r.URL.Query().Get(scopeParam),
-		Dashboard: vars["dashboard"],
-		Tab:       vars["tab"],
+		Scope:          query.Get(scopeParam),
+		Dashboard:      vars["dashboard"],
+		Tab:            vars["tab"],
+		RowFilter:      query.Get("row_filter"),
+		StatusFilter:   queryStatuses(query.Get("status_filter")),
+		MinBuildNumber: queryInt64(query, "min_build_number"),
+		MaxBuildNumber: queryInt64(query, "max_build_number"),
+		MinStartTime:   queryFloat64(query, "min_start_time"),
+		MaxStartTime:   queryFloat64(query, "max_start_time"),
+		PageSize:       int32(queryInt64(query, "page_size")),
+		PageToken:      query.Get("page_token"),
+		FieldMask:      queryFieldMask(query.Get("fields")),
+		Transform:      query.Get("transform"),
+	}
+
+	ctx, span := startSpan(r.Context(), "api.ListRows", attribute.String("dashboard", req.GetDashboard()), attribute.String("tab", req.GetTab()))
+	var err error
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+		observeRequest("ListRows", err)
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	var grid *statepb.Grid
+	var generation int64
+	grid, generation, err = s.resolveGridForHTTP(ctx, req.GetScope(), req.GetDashboard(), req.GetTab())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
 	}
-	resp, err := s.ListRows(r.Context(), &req)
+	if checkNotModified(w, r, generation) {
+		return
+	}
+
+	var resp *apipb.ListRowsResponse
+	resp, err = buildRowsResponse(grid, &req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
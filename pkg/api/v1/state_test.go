@@ -0,0 +1,75 @@
+/*
+Copyright 2021 The TestGrid Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"testing"
+
+	apipb "github.com/GoogleCloudPlatform/testgrid/pb/api/v1"
+	statepb "github.com/GoogleCloudPlatform/testgrid/pb/state"
+)
+
+func testGrid() *statepb.Grid {
+	return &statepb.Grid{
+		Columns: []*statepb.Column{
+			{Build: "1", Name: "1", Started: 100},
+			{Build: "2", Name: "2", Started: 200},
+			{Build: "3", Name: "3", Started: 300},
+		},
+		Rows: []*statepb.Row{{
+			Name:     "//row",
+			Results:  []int32{int32(statepb.Row_FAIL), 3},
+			CellIds:  []string{"1", "2", "3"},
+			Messages: []string{"m1", "m2", "m3"},
+			Icons:    []string{"", "", ""},
+		}},
+	}
+}
+
+// TestBuildRowsResponse covers buildRowsResponse directly, the logic ListRows
+// and ListRowsHTTP both delegate to, so the column-window/filter behavior is
+// tested without needing either caller's GCS/config plumbing.
+func TestBuildRowsResponse(t *testing.T) {
+	resp, err := buildRowsResponse(testGrid(), &apipb.ListRowsRequest{
+		MinBuildNumber: 2,
+		MaxBuildNumber: 3,
+	})
+	if err != nil {
+		t.Fatalf("buildRowsResponse() = %v", err)
+	}
+	if len(resp.Rows) != 1 {
+		t.Fatalf("buildRowsResponse() returned %d rows, want 1", len(resp.Rows))
+	}
+	if got := len(resp.Rows[0].Cells); got != 2 {
+		t.Errorf("buildRowsResponse() returned %d cells, want 2 (column window [2,3] not applied)", got)
+	}
+}
+
+// TestBuildHeadersResponse covers buildHeadersResponse directly, the logic
+// ListHeaders and ListHeadersHTTP both delegate to.
+func TestBuildHeadersResponse(t *testing.T) {
+	resp := buildHeadersResponse(testGrid(), &apipb.ListHeadersRequest{
+		MinBuildNumber: 2,
+		MaxBuildNumber: 3,
+	})
+	if len(resp.Headers) != 2 {
+		t.Fatalf("buildHeadersResponse() returned %d headers, want 2", len(resp.Headers))
+	}
+	if resp.Headers[0].Build != "2" || resp.Headers[1].Build != "3" {
+		t.Errorf("buildHeadersResponse() builds = [%q, %q], want [2, 3]", resp.Headers[0].Build, resp.Headers[1].Build)
+	}
+}
@@ -0,0 +1,248 @@
+/*
+Copyright 2021 The TestGrid Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	apipb "github.com/GoogleCloudPlatform/testgrid/pb/api/v1"
+	"github.com/GoogleCloudPlatform/testgrid/pkg/api/v1/transform"
+)
+
+const (
+	mimeNDJSON = "application/x-ndjson"
+	mimeSSE    = "text/event-stream"
+)
+
+// negotiateStreamFormat picks NDJSON or SSE based on the request's Accept
+// header, defaulting to NDJSON since it is the simpler format to pipe into
+// tools like jq.
+func negotiateStreamFormat(r *http.Request) string {
+	if strings.Contains(r.Header.Get("Accept"), mimeSSE) {
+		return mimeSSE
+	}
+	return mimeNDJSON
+}
+
+// streamEncoder writes one JSON-encodable value at a time to an
+// http.ResponseWriter, either as a newline-delimited JSON stream or as
+// Server-Sent Events, flushing after every write so a browser or script sees
+// rows as soon as they're decoded instead of waiting for the whole grid.
+type streamEncoder struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	format  string
+	event   string
+}
+
+func newStreamEncoder(w http.ResponseWriter, format, event string) *streamEncoder {
+	flusher, _ := w.(http.Flusher)
+	switch format {
+	case mimeSSE:
+		w.Header().Set("Content-Type", mimeSSE)
+	default:
+		w.Header().Set("Content-Type", mimeNDJSON)
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	return &streamEncoder{w: w, flusher: flusher, format: format, event: event}
+}
+
+func (e *streamEncoder) encode(v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+	switch e.format {
+	case mimeSSE:
+		if _, err := fmt.Fprintf(e.w, "event: %s\ndata: %s\n\n", e.event, body); err != nil {
+			return err
+		}
+	default:
+		if _, err := e.w.Write(append(body, '\n')); err != nil {
+			return err
+		}
+	}
+	if e.flusher != nil {
+		e.flusher.Flush()
+	}
+	return nil
+}
+
+// ListRowsStreamHTTP streams dashboard tab rows one at a time as they are
+// decoded, instead of buffering the full ListRowsResponse in memory like
+// ListRowsHTTP does. Negotiates NDJSON or SSE based on the Accept header.
+func (s Server) ListRowsStreamHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+	req := apipb.ListRowsRequest{
+		Scope:          query.Get(scopeParam),
+		Dashboard:      vars["dashboard"],
+		Tab:            vars["tab"],
+		RowFilter:      query.Get("row_filter"),
+		StatusFilter:   queryStatuses(query.Get("status_filter")),
+		MinBuildNumber: queryInt64(query, "min_build_number"),
+		MaxBuildNumber: queryInt64(query, "max_build_number"),
+		MinStartTime:   queryFloat64(query, "min_start_time"),
+		MaxStartTime:   queryFloat64(query, "max_start_time"),
+		Transform:      query.Get("transform"),
+	}
+
+	cfg, err := s.getConfig(ctx, logrus.WithContext(ctx), req.GetScope())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	cfg.Mutex.RLock()
+	defer cfg.Mutex.RUnlock()
+
+	grid, _, err := s.resolveGrid(ctx, cfg, req.GetScope(), req.GetDashboard(), req.GetTab())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	rf, err := newRowFilter(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	colStart, colEnd := rf.columnWindow(grid.Columns)
+
+	var prog *transform.Program
+	var budget *transform.Budget
+	if expr := req.GetTransform(); expr != "" {
+		prog, err = transform.Compile(expr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		budget = transform.NewBudget(transform.DefaultRequestBudget)
+	}
+
+	enc := newStreamEncoder(w, negotiateStreamFormat(r), "row")
+	for _, gRow := range grid.Rows {
+		if err := ctx.Err(); err != nil {
+			return
+		}
+		decoded := decodeRLEWindow(gRow.Results, colStart, colEnd)
+		if !rf.matchesRow(gRow, decoded) {
+			continue
+		}
+		cellsCount := len(decoded)
+		row := apipb.ListRowsResponse_Row{
+			Name:   gRow.Name,
+			Issues: gRow.Issues,
+			Alert:  gRow.AlertInfo,
+			Cells:  make([]*apipb.ListRowsResponse_Cell, 0, cellsCount),
+		}
+		for cellIdx := 0; cellIdx < cellsCount; cellIdx++ {
+			row.Cells = append(row.Cells, &apipb.ListRowsResponse_Cell{
+				Result:  decoded[cellIdx],
+				CellId:  gRow.CellIds[colStart+cellIdx],
+				Message: gRow.Messages[colStart+cellIdx],
+				Icon:    gRow.Icons[colStart+cellIdx],
+			})
+		}
+		if prog != nil {
+			keep, err := applyTransform(prog, &row, budget)
+			if err != nil {
+				logrus.WithError(err).WithField("row", row.Name).Warning("Failed to transform row")
+				return
+			}
+			if !keep {
+				continue
+			}
+		}
+		if err := enc.encode(&row); err != nil {
+			logrus.WithError(err).WithField("row", row.Name).Warning("Failed to stream row")
+			return
+		}
+	}
+}
+
+// ListHeadersStreamHTTP streams dashboard tab headers one at a time, mirroring
+// ListRowsStreamHTTP.
+func (s Server) ListHeadersStreamHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+	req := apipb.ListHeadersRequest{
+		Scope:          query.Get(scopeParam),
+		Dashboard:      vars["dashboard"],
+		Tab:            vars["tab"],
+		MinBuildNumber: queryInt64(query, "min_build_number"),
+		MaxBuildNumber: queryInt64(query, "max_build_number"),
+		MinStartTime:   queryFloat64(query, "min_start_time"),
+		MaxStartTime:   queryFloat64(query, "max_start_time"),
+	}
+
+	cfg, err := s.getConfig(ctx, logrus.WithContext(ctx), req.GetScope())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	cfg.Mutex.RLock()
+	defer cfg.Mutex.RUnlock()
+
+	grid, _, err := s.resolveGrid(ctx, cfg, req.GetScope(), req.GetDashboard(), req.GetTab())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	rf := newHeaderFilter(&req)
+	start, end := rf.columnWindow(grid.Columns)
+
+	enc := newStreamEncoder(w, negotiateStreamFormat(r), "header")
+	for _, gColumn := range grid.Columns[start:end] {
+		if err := ctx.Err(); err != nil {
+			return
+		}
+		// TODO(#683): Remove timestamp conversion math
+		millis := gColumn.Started
+		sec := millis / 1000
+		nanos := math.Mod(millis, 1000) * 1e6
+		header := apipb.ListHeadersResponse_Header{
+			Name:  gColumn.Name,
+			Build: gColumn.Build,
+			Started: &timestamp.Timestamp{
+				Seconds: int64(sec),
+				Nanos:   int32(nanos),
+			},
+			Extra:      gColumn.Extra,
+			HotlistIds: gColumn.HotlistIds,
+		}
+		if err := enc.encode(&header); err != nil {
+			logrus.WithError(err).WithField("header", header.Name).Warning("Failed to stream header")
+			return
+		}
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2021 The TestGrid Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestNegotiateStreamFormat(t *testing.T) {
+	cases := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{"empty", "", mimeNDJSON},
+		{"ndjson explicit", mimeNDJSON, mimeNDJSON},
+		{"sse", mimeSSE, mimeSSE},
+		{"sse among others", "text/html, text/event-stream;q=0.9", mimeSSE},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept", tc.accept)
+			if got := negotiateStreamFormat(r); got != tc.want {
+				t.Errorf("negotiateStreamFormat(%q) = %q, want %q", tc.accept, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStreamEncoderEncode(t *testing.T) {
+	t.Run("ndjson", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		enc := newStreamEncoder(w, mimeNDJSON, "row")
+		if err := enc.encode(map[string]string{"name": "a"}); err != nil {
+			t.Fatalf("encode() = %v", err)
+		}
+		if err := enc.encode(map[string]string{"name": "b"}); err != nil {
+			t.Fatalf("encode() = %v", err)
+		}
+		want := "{\"name\":\"a\"}\n{\"name\":\"b\"}\n"
+		if got := w.Body.String(); got != want {
+			t.Errorf("body = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("sse", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		enc := newStreamEncoder(w, mimeSSE, "row")
+		if err := enc.encode(map[string]string{"name": "a"}); err != nil {
+			t.Fatalf("encode() = %v", err)
+		}
+		want := "event: row\ndata: {\"name\":\"a\"}\n\n"
+		if got := w.Body.String(); got != want {
+			t.Errorf("body = %q, want %q", got, want)
+		}
+	})
+}
+
+// TestRouterRegistersStreamRoutes guards against the streaming handlers
+// silently going unreachable: it confirms Router wires GET requests for the
+// rows/headers stream paths to a handler instead of 404ing before the request
+// ever reaches ListRowsStreamHTTP/ListHeadersStreamHTTP.
+func TestRouterRegistersStreamRoutes(t *testing.T) {
+	router := mux.NewRouter()
+	Router(router, &Server{})
+
+	paths := []string{
+		"/dashboards/d/tabs/t/rows/stream",
+		"/dashboards/d/tabs/t/headers/stream",
+	}
+	for _, p := range paths {
+		req := httptest.NewRequest(http.MethodGet, p, nil)
+		var match mux.RouteMatch
+		if !router.Match(req, &match) {
+			t.Errorf("Router() has no route for GET %s", p)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+/*
+Copyright 2021 The TestGrid Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package transform lets callers of the v1 API supply a Common Expression
+// Language program that runs against each row (and each row's cells) after
+// decodeRLE, so a caller can derive computed fields, drop rows that don't
+// match a predicate, or rewrite messages without the API hard-coding every
+// aggregation a dashboard might want.
+//
+// The CEL environment is intentionally narrow: it exposes only the Row and
+// Cell fields a caller already gets back from ListRows, plus a small library
+// of helper functions (e.g. flake_rate), and evaluation is bounded two ways:
+// maxEvalCost limits any single Eval call, and a caller-supplied Budget limits
+// how many Eval calls a whole request may spend across every row and cell, so
+// a grid with many rows times many columns can't turn into an unbounded
+// aggregate workload.
+package transform
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+
+	apipb "github.com/GoogleCloudPlatform/testgrid/pb/api/v1"
+	statepb "github.com/GoogleCloudPlatform/testgrid/pb/state"
+)
+
+// maxEvalCost bounds the estimated per-expression evaluation cost CEL will
+// run before aborting, so a pathological expression can't turn a single
+// request into a denial of service.
+const maxEvalCost = 10_000
+
+// DefaultRequestBudget is the number of Eval calls ListRows and its streaming
+// equivalents allow a single request's transform to spend in aggregate,
+// across every row and cell in the response.
+const DefaultRequestBudget = 50_000
+
+// Program is a compiled, ready-to-evaluate CEL transform.
+type Program struct {
+	ast *cel.Ast
+	prg cel.Program
+}
+
+// Budget bounds the total number of Eval calls a single request may spend
+// across every row and cell it runs a Program against. maxEvalCost alone
+// only bounds one call; without a Budget, a grid with many rows times many
+// columns turns into an unbounded aggregate CEL workload even though no
+// individual Eval ever exceeds its own cost limit.
+type Budget struct {
+	remaining int
+}
+
+// NewBudget returns a Budget allowing up to n total Eval calls.
+func NewBudget(n int) *Budget {
+	return &Budget{remaining: n}
+}
+
+// take consumes one unit of budget, returning an error once exhausted. A nil
+// Budget is unbounded, for callers that evaluate a Program outside a
+// request's lifecycle (e.g. this package's own tests).
+func (b *Budget) take() error {
+	if b == nil {
+		return nil
+	}
+	if b.remaining <= 0 {
+		return fmt.Errorf("evaluation budget exhausted")
+	}
+	b.remaining--
+	return nil
+}
+
+// env is the shared, strict CEL environment: it declares only the row and
+// cell variables (string-keyed maps filled by rowToCEL and cellToCEL), plus
+// the flake_rate helper, and nothing from the surrounding Go process.
+var env = mustNewEnv()
+
+func mustNewEnv() *cel.Env {
+	e, err := cel.NewEnv(
+		cel.Variable("row", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("cell", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Function("flake_rate",
+			cel.Overload("flake_rate_list_int",
+				[]*cel.Type{cel.ListType(cel.IntType)},
+				cel.DoubleType,
+				cel.UnaryBinding(flakeRate),
+			),
+		),
+	)
+	if err != nil {
+		panic(fmt.Sprintf("transform: building CEL environment: %v", err))
+	}
+	return e
+}
+
+// Compile parses and type-checks expr against the row/cell environment,
+// returning a Program ready to be run per row.
+func Compile(expr string) (*Program, error) {
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("compile %q: %w", expr, issues.Err())
+	}
+	prg, err := env.Program(ast, cel.CostLimit(maxEvalCost))
+	if err != nil {
+		return nil, fmt.Errorf("plan %q: %w", expr, err)
+	}
+	return &Program{ast: ast, prg: prg}, nil
+}
+
+// EvalRow runs the program against row, returning keep=false if a boolean
+// result is false (the row is dropped), or the result's CEL representation
+// otherwise (e.g. a computed value a caller asked for). budget is charged one
+// unit; a nil budget is unbounded.
+func (p *Program) EvalRow(row *apipb.ListRowsResponse_Row, budget *Budget) (ref.Val, bool, error) {
+	if err := budget.take(); err != nil {
+		return nil, false, err
+	}
+	out, _, err := p.prg.Eval(map[string]interface{}{
+		"row":  rowToCEL(row),
+		"cell": map[string]interface{}{},
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("eval: %w", err)
+	}
+	if b, ok := out.(types.Bool); ok {
+		return out, bool(b), nil
+	}
+	return out, true, nil
+}
+
+// EvalCell runs the program against a single cell of row, for expressions
+// that rewrite or derive a per-cell value (e.g. message rewriting). budget is
+// charged one unit; a nil budget is unbounded.
+func (p *Program) EvalCell(row *apipb.ListRowsResponse_Row, cell *apipb.ListRowsResponse_Cell, budget *Budget) (ref.Val, error) {
+	if err := budget.take(); err != nil {
+		return nil, err
+	}
+	out, _, err := p.prg.Eval(map[string]interface{}{
+		"row":  rowToCEL(row),
+		"cell": cellToCEL(cell),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("eval: %w", err)
+	}
+	return out, nil
+}
+
+// rowToCEL projects the subset of a Row that the transform environment
+// exposes; notably it excludes the raw proto so expressions can't reach
+// fields outside this contract.
+func rowToCEL(row *apipb.ListRowsResponse_Row) map[string]interface{} {
+	cells := make([]interface{}, 0, len(row.GetCells()))
+	for _, c := range row.GetCells() {
+		cells = append(cells, cellToCEL(c))
+	}
+	return map[string]interface{}{
+		"name":  row.GetName(),
+		"cells": cells,
+	}
+}
+
+func cellToCEL(cell *apipb.ListRowsResponse_Cell) map[string]interface{} {
+	return map[string]interface{}{
+		"result":  int64(cell.GetResult()),
+		"cell_id": cell.GetCellId(),
+		"message": cell.GetMessage(),
+		"icon":    cell.GetIcon(),
+	}
+}
+
+// flakeRate implements the flake_rate(results) helper: the fraction of the
+// given per-cell results (as statepb.Row_Result ints) that are FLAKY,
+// letting a transform expression derive a flake-rate column without the API
+// hard-coding that aggregation.
+func flakeRate(arg ref.Val) ref.Val {
+	list, ok := arg.(traits.Lister)
+	if !ok {
+		return types.NewErr("flake_rate: expected list of int")
+	}
+	sz := list.Size().(types.Int)
+	if sz == 0 {
+		return types.Double(0)
+	}
+	var flaky int64
+	for i := types.Int(0); i < sz; i++ {
+		v := list.Get(i)
+		if iv, ok := v.(types.Int); ok && int32(iv) == int32(statepb.Row_FLAKY) {
+			flaky++
+		}
+	}
+	return types.Double(float64(flaky) / float64(sz))
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2021 The TestGrid Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package transform
+
+import (
+	"testing"
+
+	apipb "github.com/GoogleCloudPlatform/testgrid/pb/api/v1"
+	statepb "github.com/GoogleCloudPlatform/testgrid/pb/state"
+)
+
+func TestEvalRowFiltersOnBooleanResult(t *testing.T) {
+	prog, err := Compile(`row["name"] == "keep-me"`)
+	if err != nil {
+		t.Fatalf("Compile() = %v", err)
+	}
+
+	kept := &apipb.ListRowsResponse_Row{Name: "keep-me"}
+	if _, keep, err := prog.EvalRow(kept, nil); err != nil || !keep {
+		t.Errorf("EvalRow(%q) = (keep=%v, err=%v), want (true, nil)", kept.Name, keep, err)
+	}
+
+	dropped := &apipb.ListRowsResponse_Row{Name: "drop-me"}
+	if _, keep, err := prog.EvalRow(dropped, nil); err != nil || keep {
+		t.Errorf("EvalRow(%q) = (keep=%v, err=%v), want (false, nil)", dropped.Name, keep, err)
+	}
+}
+
+func TestEvalCell(t *testing.T) {
+	prog, err := Compile(`cell["result"]`)
+	if err != nil {
+		t.Fatalf("Compile() = %v", err)
+	}
+	row := &apipb.ListRowsResponse_Row{Name: "r"}
+	cell := &apipb.ListRowsResponse_Cell{Result: int32(statepb.Row_FAIL)}
+	out, err := prog.EvalCell(row, cell, nil)
+	if err != nil {
+		t.Fatalf("EvalCell() = %v", err)
+	}
+	if got := out.Value().(int64); got != int64(statepb.Row_FAIL) {
+		t.Errorf("EvalCell() = %v, want %v", got, statepb.Row_FAIL)
+	}
+}
+
+func TestBudgetExhausted(t *testing.T) {
+	prog, err := Compile(`row["name"] == ""`)
+	if err != nil {
+		t.Fatalf("Compile() = %v", err)
+	}
+	row := &apipb.ListRowsResponse_Row{Name: "r"}
+	budget := NewBudget(2)
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := prog.EvalRow(row, budget); err != nil {
+			t.Fatalf("EvalRow() call %d = %v, want nil (budget not yet exhausted)", i, err)
+		}
+	}
+	if _, _, err := prog.EvalRow(row, budget); err == nil {
+		t.Error("EvalRow() after budget exhausted = nil error, want error")
+	}
+}
+
+func TestNilBudgetIsUnbounded(t *testing.T) {
+	prog, err := Compile(`row["name"] == ""`)
+	if err != nil {
+		t.Fatalf("Compile() = %v", err)
+	}
+	row := &apipb.ListRowsResponse_Row{Name: "r"}
+	for i := 0; i < 100; i++ {
+		if _, _, err := prog.EvalRow(row, nil); err != nil {
+			t.Fatalf("EvalRow() call %d with nil budget = %v, want nil", i, err)
+		}
+	}
+}
+
+func TestFlakeRate(t *testing.T) {
+	prog, err := Compile(`flake_rate(row["cells"].map(c, c["result"]))`)
+	if err != nil {
+		t.Fatalf("Compile() = %v", err)
+	}
+
+	cases := []struct {
+		name    string
+		results []int32
+		want    float64
+	}{
+		{"empty", nil, 0},
+		{"no flakes", []int32{int32(statepb.Row_PASS), int32(statepb.Row_FAIL)}, 0},
+		{"all flaky", []int32{int32(statepb.Row_FLAKY), int32(statepb.Row_FLAKY)}, 1},
+		{"half flaky", []int32{int32(statepb.Row_FLAKY), int32(statepb.Row_PASS)}, 0.5},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			row := &apipb.ListRowsResponse_Row{Name: "r"}
+			for _, r := range tc.results {
+				row.Cells = append(row.Cells, &apipb.ListRowsResponse_Cell{Result: r})
+			}
+			out, keep, err := prog.EvalRow(row, nil)
+			if err != nil {
+				t.Fatalf("EvalRow() = %v", err)
+			}
+			if !keep {
+				t.Fatalf("EvalRow() keep = false, want true (non-boolean result)")
+			}
+			if got := out.Value().(float64); got != tc.want {
+				t.Errorf("flake_rate(%v) = %v, want %v", tc.results, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2021 The TestGrid Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image/color"
+	"image/png"
+
+	"github.com/chromedp/chromedp"
+)
+
+// LoadDashboard navigates to a dashboard page served by the harness and waits
+// for its tab buttons to render.
+func (h *Harness) LoadDashboard(dashboard string) chromedp.Action {
+	url := fmt.Sprintf("%s/dashboards/%s", h.Server.URL, dashboard)
+	return chromedp.Tasks{
+		chromedp.Navigate(url),
+		chromedp.WaitVisible(`[data-testid="dashboard-tabs"]`, chromedp.ByQuery),
+	}
+}
+
+// ExpandTab clicks the named tab within the currently loaded dashboard and
+// waits for its grid to render, i.e. for the ListRows response to have been
+// fetched and painted.
+func (h *Harness) ExpandTab(tab string) chromedp.Action {
+	selector := fmt.Sprintf(`[data-testid="tab-%s"]`, tab)
+	return chromedp.Tasks{
+		chromedp.Click(selector, chromedp.ByQuery),
+		chromedp.WaitVisible(`[data-testid="grid-rows"]`, chromedp.ByQuery),
+	}
+}
+
+// ClickCell clicks the cell at (rowName, columnIndex) in the rendered grid
+// and returns the message text shown in the resulting tooltip/popup.
+func (h *Harness) ClickCell(rowName string, columnIndex int, message *string) chromedp.Action {
+	selector := fmt.Sprintf(`[data-testid="cell-%s-%d"]`, rowName, columnIndex)
+	return chromedp.Tasks{
+		chromedp.Click(selector, chromedp.ByQuery),
+		chromedp.WaitVisible(`[data-testid="cell-tooltip"]`, chromedp.ByQuery),
+		chromedp.Text(`[data-testid="cell-tooltip"]`, message, chromedp.ByQuery),
+	}
+}
+
+// ScreenshotDiff captures a full-page screenshot and reports whether it
+// differs from baseline by more than maxDiffPixels, a cheap perceptual check
+// rather than a byte-exact comparison (anti-aliasing and font hinting vary
+// across machines).
+func ScreenshotDiff(baseline []byte, maxDiffPixels int) (chromedp.Action, *bool) {
+	var shot []byte
+	differs := new(bool)
+	action := chromedp.Tasks{
+		chromedp.FullScreenshot(&shot, 100), // quality 100 yields a PNG, which countDiffPixels decodes
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			diff, err := countDiffPixels(baseline, shot)
+			if err != nil {
+				return err
+			}
+			*differs = diff > maxDiffPixels
+			return nil
+		}),
+	}
+	return action, differs
+}
+
+// countDiffPixels returns the number of pixels that differ between two
+// same-sized PNG images. Mismatched dimensions count as fully different.
+func countDiffPixels(a, b []byte) (int, error) {
+	imgA, err := png.Decode(bytes.NewReader(a))
+	if err != nil {
+		return 0, fmt.Errorf("decode baseline: %w", err)
+	}
+	imgB, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		return 0, fmt.Errorf("decode screenshot: %w", err)
+	}
+	boundsA, boundsB := imgA.Bounds(), imgB.Bounds()
+	if boundsA != boundsB {
+		return boundsA.Dx() * boundsA.Dy(), nil
+	}
+
+	var diff int
+	for y := boundsA.Min.Y; y < boundsA.Max.Y; y++ {
+		for x := boundsA.Min.X; x < boundsA.Max.X; x++ {
+			if !colorsEqual(imgA.At(x, y), imgB.At(x, y)) {
+				diff++
+			}
+		}
+	}
+	return diff, nil
+}
+
+func colorsEqual(a, b color.Color) bool {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	return ar == br && ag == bg && ab == bb && aa == ba
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2021 The TestGrid Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"context"
+	"flag"
+	"math/rand"
+	"testing"
+	"time"
+
+	statepb "github.com/GoogleCloudPlatform/testgrid/pb/state"
+	v1 "github.com/GoogleCloudPlatform/testgrid/pkg/api/v1"
+	fakegcs "github.com/GoogleCloudPlatform/testgrid/util/gcs/fake"
+)
+
+var (
+	headless = flag.Bool("headless", true, "Run Chromium headless; pass -headless=false to watch the browser locally")
+	seed     = flag.Int64("seed", 1, "Seed for randomizing which dashboards/tabs/cells the soak test exercises")
+	duration = flag.Duration("duration", 0, "If non-zero, keep exercising ListHeadersHTTP/ListRowsHTTP via the browser for this long instead of running once")
+)
+
+// exampleTabs is the dashboard/tab fixture every test in this file drives:
+// one dashboard, one tab, one row with a single failing cell, so
+// ClickCell("//example-row", 0, ...) always has a deterministic message to
+// assert against.
+var exampleTabs = map[string][]string{"example-dashboard": {"example-tab"}}
+
+// newFixtureServer builds a v1.Server backed by an in-memory GCS fake seeded
+// with a TestGrid config and a grid for example-dashboard/example-tab, so
+// the harness's browser can actually resolve ListRows/ListHeaders requests
+// instead of 404ing on an empty config.
+func newFixtureServer(t *testing.T) *v1.Server {
+	t.Helper()
+
+	client := fakegcs.NewClient()
+	grid := &statepb.Grid{
+		Columns: []*statepb.Column{{Build: "1", Name: "1"}},
+		Rows: []*statepb.Row{{
+			Name:     "//example-row",
+			Results:  []int32{int32(statepb.Row_FAIL), 1},
+			CellIds:  []string{"1"},
+			Messages: []string{"example failure message"},
+			Icons:    []string{""},
+		}},
+	}
+	if err := fakegcs.SeedTestGrid(client, "example-dashboard", "example-tab", grid); err != nil {
+		t.Fatalf("seed fixture grid: %v", err)
+	}
+
+	return &v1.Server{
+		Client:         client,
+		GridPathPrefix: "grid",
+		TabPathPrefix:  "tabs",
+		Timeout:        10 * time.Second,
+		Cache:          v1.NewGridCache(0, 0),
+	}
+}
+
+// TestDashboardRendersRows loads a fixture dashboard, expands its one tab,
+// and asserts that clicking the first cell shows a non-empty tooltip message.
+func TestDashboardRendersRows(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping chromedp-driven test in -short mode")
+	}
+
+	server := newFixtureServer(t)
+	h, err := New(context.Background(), Options{APIServer: server, Tabs: exampleTabs, Headless: *headless})
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	defer h.Close()
+
+	var message string
+	err = h.Run(30*time.Second,
+		h.LoadDashboard("example-dashboard"),
+		h.ExpandTab("example-tab"),
+		h.ClickCell("//example-row", 0, &message),
+	)
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if message == "" {
+		t.Error("ClickCell() produced an empty tooltip message, want the cell's ListRows message")
+	}
+}
+
+// TestSoak continuously drives ListHeadersHTTP/ListRowsHTTP through the
+// browser for -duration, as a scale/soak test of the API under realistic
+// browser load rather than synthetic load generation. It is a no-op unless
+// -duration is set, so `go test ./pkg/e2e/...` stays fast by default.
+func TestSoak(t *testing.T) {
+	if *duration == 0 {
+		t.Skip("set -duration to run the soak test")
+	}
+
+	server := newFixtureServer(t)
+	h, err := New(context.Background(), Options{APIServer: server, Tabs: exampleTabs, Headless: *headless})
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	defer h.Close()
+
+	rng := rand.New(rand.NewSource(*seed))
+	dashboards := []string{"example-dashboard"}
+	tabs := []string{"example-tab"}
+
+	deadline := time.Now().Add(*duration)
+	var iterations int
+	for time.Now().Before(deadline) {
+		dashboard := dashboards[rng.Intn(len(dashboards))]
+		tab := tabs[rng.Intn(len(tabs))]
+		if err := h.Run(30*time.Second, h.LoadDashboard(dashboard), h.ExpandTab(tab)); err != nil {
+			t.Fatalf("iteration %d: Run() = %v", iterations, err)
+		}
+		iterations++
+	}
+	t.Logf("completed %d soak iterations in %s", iterations, *duration)
+}
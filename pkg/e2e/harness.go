@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The TestGrid Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package e2e drives a headless Chromium against a TestGrid API server backed
+// by a fixture GCS bucket, so dashboard/tab pages can be exercised the way a
+// real user's browser would. The same actions compose into functional tests
+// and into a long-running scale/soak test.
+package e2e
+
+import (
+	"context"
+	"net/http/httptest"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/gorilla/mux"
+
+	v1 "github.com/GoogleCloudPlatform/testgrid/pkg/api/v1"
+)
+
+// Harness bundles a TestGrid API server backed by a fixture GCS client with a
+// headless Chromium instance driving requests against it.
+type Harness struct {
+	Server *httptest.Server
+
+	allocCtx    context.Context
+	allocCancel context.CancelFunc
+	browserCtx  context.Context
+	browserDone context.CancelFunc
+}
+
+// Options configure a Harness.
+type Options struct {
+	// APIServer backs the dashboard/tab pages the browser loads. Tests
+	// construct it against a fixture or in-memory GCS client.
+	APIServer *v1.Server
+	// Tabs lists, per dashboard name, the tabs the stub UI should render as
+	// clickable buttons; it stands in for the real frontend's dashboard
+	// config, which this harness doesn't have access to.
+	Tabs map[string][]string
+	// Headless runs Chromium without a visible window; false is useful
+	// when debugging a failure locally.
+	Headless bool
+}
+
+// New starts an httptest server wrapping opts.APIServer and a headless
+// Chromium instance pointed at it.
+func New(ctx context.Context, opts Options) (*Harness, error) {
+	router := mux.NewRouter()
+	v1.Router(router, opts.APIServer)
+	stubUI(router, opts.Tabs)
+	server := httptest.NewServer(router)
+
+	allocOpts := append([]chromedp.ExecAllocatorOption{}, chromedp.DefaultExecAllocatorOptions[:]...)
+	allocOpts = append(allocOpts, chromedp.Flag("headless", opts.Headless))
+	allocCtx, allocCancel := chromedp.NewExecAllocator(ctx, allocOpts...)
+
+	browserCtx, browserDone := chromedp.NewContext(allocCtx)
+	if err := chromedp.Run(browserCtx); err != nil { // force the browser to start now, not on first Action
+		browserDone()
+		allocCancel()
+		server.Close()
+		return nil, err
+	}
+
+	return &Harness{
+		Server:      server,
+		allocCtx:    allocCtx,
+		allocCancel: allocCancel,
+		browserCtx:  browserCtx,
+		browserDone: browserDone,
+	}, nil
+}
+
+// Run executes actions against the harness's browser context, applying
+// timeout as an overall deadline.
+func (h *Harness) Run(timeout time.Duration, actions ...chromedp.Action) error {
+	ctx, cancel := context.WithTimeout(h.browserCtx, timeout)
+	defer cancel()
+	return chromedp.Run(ctx, actions...)
+}
+
+// Close tears down the browser and the fixture API server.
+func (h *Harness) Close() {
+	h.browserDone()
+	h.allocCancel()
+	h.Server.Close()
+}
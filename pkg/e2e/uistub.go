@@ -0,0 +1,86 @@
+/*
+Copyright 2021 The TestGrid Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// dashboardPageTmpl renders a minimal dashboard page: a row of tab buttons,
+// and a script that fetches ListRowsHTTP/ListHeadersHTTP for whichever tab is
+// selected and paints the grid, wiring up the data-testid hooks actions.go
+// drives. It stands in for the real TestGrid frontend, which lives outside
+// this package and isn't part of this harness.
+var dashboardPageTmpl = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html><body>
+<div data-testid="dashboard-tabs">
+{{range .Tabs}}<button data-testid="tab-{{.}}" onclick="loadTab('{{.}}')">{{.}}</button>{{end}}
+</div>
+<div data-testid="grid-rows" style="display:none"></div>
+<div data-testid="cell-tooltip" style="display:none"></div>
+<script>
+function loadTab(tab) {
+  fetch('/dashboards/{{.Dashboard}}/tabs/' + tab + '/headers');
+  fetch('/dashboards/{{.Dashboard}}/tabs/' + tab + '/rows')
+    .then(r => r.json())
+    .then(data => renderRows(data.rows || []));
+}
+function renderRows(rows) {
+  const container = document.querySelector('[data-testid="grid-rows"]');
+  container.innerHTML = '';
+  rows.forEach(row => {
+    const cells = row.cells || [];
+    cells.forEach((cell, i) => {
+      const el = document.createElement('span');
+      el.setAttribute('data-testid', 'cell-' + row.name + '-' + i);
+      el.textContent = cell.result;
+      el.onclick = () => showTooltip(cell.message || '');
+      container.appendChild(el);
+    });
+  });
+  container.style.display = 'block';
+}
+function showTooltip(message) {
+  const tip = document.querySelector('[data-testid="cell-tooltip"]');
+  tip.textContent = message;
+  tip.style.display = 'block';
+}
+</script>
+</body></html>`))
+
+type dashboardPageData struct {
+	Dashboard string
+	Tabs      []string
+}
+
+// stubUI registers a minimal HTML dashboard page per entry in tabs (dashboard
+// name -> its tab names) onto router, so the harness's browser has real
+// markup to load, expand, and click cells in.
+func stubUI(router *mux.Router, tabs map[string][]string) {
+	router.HandleFunc("/dashboards/{dashboard}", func(w http.ResponseWriter, r *http.Request) {
+		dashboard := mux.Vars(r)["dashboard"]
+		data := dashboardPageData{Dashboard: dashboard, Tabs: tabs[dashboard]}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := dashboardPageTmpl.Execute(w, data); err != nil {
+			http.Error(w, fmt.Sprintf("render dashboard page: %v", err), http.StatusInternalServerError)
+		}
+	}).Methods(http.MethodGet)
+}